@@ -10,15 +10,13 @@ import (
 	"github.com/l3uddz/nabarr/build"
 	"github.com/l3uddz/nabarr/cache"
 	"github.com/l3uddz/nabarr/cmd/nabarr/pvr"
+	"github.com/l3uddz/nabarr/logger"
 	"github.com/l3uddz/nabarr/media"
+	"github.com/l3uddz/nabarr/metrics"
 	"github.com/l3uddz/nabarr/peernet"
 	"github.com/l3uddz/nabarr/rss"
 	"github.com/l3uddz/nabarr/util"
 	"github.com/lefelys/state"
-	"github.com/natefinch/lumberjack"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -26,10 +24,12 @@ import (
 )
 
 type config struct {
+	Logging logger.Config      `yaml:"logging"`
 	Media   media.Config       `yaml:"media"`
 	Pvrs    []nabarr.PvrConfig `yaml:"pvrs"`
 	Rss     rss.Config         `yaml:"rss"`
 	Peernet peernet.Config     `yaml:"peernet"`
+	Metrics metrics.Config     `yaml:"metrics"`
 }
 
 var (
@@ -50,6 +50,14 @@ var (
 			Id       string `type:"string" required:"1" help:"Metadata ID of item to test" placeholder:"tvdb:121361"`
 			AllowAdd bool   `type:"bool" default:"0" required:"0" help:"Add item"`
 		} `cmd help:"Test your filters and stop"`
+		Peer struct {
+			Keygen struct{} `cmd help:"Generate a fresh identity key and network key"`
+			Id     struct{} `cmd help:"Print this node's peer ID and advertised multiaddrs"`
+			Ping   struct {
+				Multiaddr string `arg help:"Multiaddr of the peer to ping" placeholder:"/ip4/1.2.3.4/tcp/9157/p2p/Qm..."`
+			} `cmd help:"Connect to a peer and report how long the connection took to establish"`
+			Peers struct{} `cmd help:"List known peers and gossipsub mesh membership per topic"`
+		} `cmd help:"Identity, keygen, and network diagnostics"`
 	}
 )
 
@@ -86,35 +94,27 @@ func main() {
 		cli.Verbosity = 1
 	}
 
-	// logger
-	logger := log.Output(io.MultiWriter(zerolog.ConsoleWriter{
-		TimeFormat: time.Stamp,
-		Out:        os.Stderr,
-	}, zerolog.ConsoleWriter{
-		TimeFormat: time.Stamp,
-		Out: &lumberjack.Logger{
-			Filename:   cli.Log,
-			MaxSize:    5,
-			MaxAge:     14,
-			MaxBackups: 5,
-		},
-		NoColor: true,
-	}))
+	// keygen needs neither a config file nor a running node, so handle it before either exists
+	if ctx.Command() == "peer keygen" {
+		if err := peerKeygen(); err != nil {
+			fmt.Println("Failed generating identity:", err)
+		}
+		return
+	}
 
-	switch {
-	case cli.Verbosity == 1:
-		log.Logger = logger.Level(zerolog.DebugLevel)
-	case cli.Verbosity > 1:
-		log.Logger = logger.Level(zerolog.TraceLevel)
-	default:
-		log.Logger = logger.Level(zerolog.InfoLevel)
+	// bootstrap logger: used only until the config file (and its logging: block) is decoded
+	bootstrapRoot, err := logger.New(logger.Config{Level: cliVerbosityLevel()}, cli.Log)
+	if err != nil {
+		fmt.Println("Failed initialising logger:", err)
+		return
 	}
+	mainLog := bootstrapRoot.Sub("main")
 
 	// config
-	log.Trace().Msg("Initialising config")
+	mainLog.Trace().Msg("Initialising config")
 	file, err := os.Open(cli.Config)
 	if err != nil {
-		log.Error().
+		mainLog.Error().
 			Err(err).
 			Msg("Failed opening config")
 		return
@@ -125,32 +125,56 @@ func main() {
 	decoder := yaml.NewDecoder(file, yaml.Strict())
 	err = decoder.Decode(&cfg)
 	if err != nil {
-		log.Error().Msg("Failed decoding configuration")
-		log.Error().Msg(err.Error())
+		mainLog.Error().Msg("Failed decoding configuration")
+		mainLog.Error().Msg(err.Error())
 		return
 	}
 
+	// the config file's logging: block takes over now, with the cli verbosity flag still able to
+	// force a more verbose level
+	if cli.Verbosity > 0 {
+		cfg.Logging.Level = cliVerbosityLevel()
+	}
+	root, err := logger.New(cfg.Logging, cli.Log)
+	if err != nil {
+		mainLog.Error().
+			Err(err).
+			Msg("Failed initialising logger")
+		return
+	}
+	mainLog = root.Sub("main")
+
 	// cache
-	c, err := cache.New(cli.Cache)
+	c, err := cache.New(cli.Cache, root.Sub("cache"))
 	if err != nil {
-		log.Error().
+		mainLog.Error().
 			Err(err).
 			Msg("Failed initialising cache")
 		return
 	}
 	defer func() {
 		if err := c.Close(); err != nil {
-			log.Error().
+			mainLog.Error().
 				Err(err).
 				Msg("Failed closing cache gracefully")
 		}
 	}()
 
+	// the remaining peer (identity/diagnostics) commands short-circuit the rest of main
+	if strings.HasPrefix(ctx.Command(), "peer ") {
+		if err := runPeerCommand(ctx.Command(), cfg, c, root); err != nil {
+			mainLog.Error().
+				Err(err).
+				Msg("Peer command failed")
+		}
+		return
+	}
+
 	// media
-	log.Trace().Msg("Initialising media")
-	m, err := media.New(&cfg.Media)
+	mainLog.Trace().Msg("Initialising media")
+	m, err := media.New(&cfg.Media, root.Sub("media"))
 	if err != nil {
-		log.Error().
+		mainLog.Error().
 			Err(err).
 			Msg("Failed initialising media")
 		return
@@ -159,9 +183,11 @@ func main() {
 	// states
 	pvrStates := make([]state.State, 0)
 	rssState := state.Empty()
+	metricsShutdown := func(context.Context) error { return nil }
+	adminShutdown := func(context.Context) error { return nil }
 
 	// pvrs
-	log.Trace().Msg("Initialising pvrs")
+	mainLog.Trace().Msg("Initialising pvrs")
 
 	pvrs := make(map[string]pvr.PVR, 0)
 	for _, p := range cfg.Pvrs {
@@ -172,9 +198,9 @@ func main() {
 				mode = "test-add"
 			}
 
-			po, err := pvr.NewPVR(p, mode, m, c)
+			po, err := pvr.NewPVR(p, mode, m, c, root.Sub("pvr:"+p.Name))
 			if err != nil {
-				log.Error().
+				mainLog.Error().
 					Err(err).
 					Str("pvr", p.Name).
 					Msg("Failed initialising pvr")
@@ -191,10 +217,20 @@ func main() {
 
 	// run mode (start rss scheduler and wait for shutdown signal)
 	if ctx.Command() == "run" {
+		// metrics
+		var err error
+		metricsShutdown, err = metrics.Serve(cfg.Metrics)
+		if err != nil {
+			mainLog.Error().
+				Err(err).
+				Msg("Failed starting metrics server")
+			return
+		}
+
 		// peernet
-		pn, err := peernet.New(cfg.Peernet)
+		pn, err := peernet.New(cfg.Peernet, c, root.Sub("peernet"))
 		if err != nil {
-			log.Error().
+			mainLog.Error().
 				Err(err).
 				Msg("Failed initialising peernet")
 			return
@@ -208,21 +244,42 @@ func main() {
 				// decode data
 				fi := new(media.FeedItem)
 				if err := json.Unmarshal(data, fi); err != nil {
-					log.Error().
+					mainLog.Error().
 						Err(err).
 						Msg("Failed decoding feed item from peernet")
 					return
 				}
 
+				// skip items we've already queued, whether seen locally via rss or forwarded by
+				// another peer in the mesh - gossipsub will naturally re-deliver messages
+				key := peernet.HashKey(fi.Guid, fi.ImdbId, fi.TvdbId, fi.TmdbId, fi.Title)
+				seen, err := peernet.Seen(c, key, pn.DedupTTL())
+				if err != nil {
+					mainLog.Error().
+						Err(err).
+						Msg("Failed checking peernet dedup cache")
+					return
+				}
+				if seen {
+					mainLog.Debug().
+						Str("guid", fi.Guid).
+						Msg("Skipping already-seen peernet feed item")
+					return
+				}
+
 				// send to pvr(s)
-				for _, p := range pvrs {
+				for name, p := range pvrs {
 					switch {
 					case (fi.TvdbId != "" || fi.TmdbId != "") && util.ContainsTvCategory(fi.Category) && p.Type() == "sonarr":
 						// tvdbId/tmdbId is present, queue with sonarr
 						p.QueueFeedItem(fi)
+						metrics.PvrItemsQueued.WithLabelValues(name).Inc()
 					case (fi.ImdbId != "" || fi.TmdbId != "") && util.ContainsMovieCategory(fi.Category) && p.Type() == "radarr":
 						// imdbId is present, queue with radarr
 						p.QueueFeedItem(fi)
+						metrics.PvrItemsQueued.WithLabelValues(name).Inc()
+					default:
+						metrics.PvrItemsFiltered.WithLabelValues(name).Inc()
 					}
 				}
 
@@ -232,32 +289,40 @@ func main() {
 		}
 
 		if err := pn.Subscribe("sonarr", pnHandler); err != nil {
-			log.Error().
+			mainLog.Error().
 				Err(err).
 				Msg("Failed subscribing to sonarr peernet topic")
 			return
 		}
 
 		if err := pn.Subscribe("radarr", pnHandler); err != nil {
-			log.Error().
+			mainLog.Error().
 				Err(err).
 				Msg("Failed subscribing to radarr peernet topic")
 			return
 		}
 
 		if err := pn.Bootstrap(); err != nil {
-			log.Error().
+			mainLog.Error().
 				Err(err).
 				Msg("Failed bootstrapping peernet")
 			return
 		}
 
+		adminShutdown, err = pn.ServeAdmin(cfg.Peernet.AdminAddr)
+		if err != nil {
+			mainLog.Error().
+				Err(err).
+				Msg("Failed starting peernet admin server")
+			return
+		}
+
 		// rss
-		log.Trace().Msg("Initialising rss")
-		r := rss.New(cfg.Rss, c, pvrs)
+		mainLog.Trace().Msg("Initialising rss")
+		r := rss.New(cfg.Rss, c, pvrs, root.Sub("rss"))
 		for _, feed := range cfg.Rss.Feeds {
 			if err := r.AddJob(feed, pn); err != nil {
-				log.Error().
+				mainLog.Error().
 					Err(err).
 					Msg("Failed initialising rss")
 				return
@@ -271,7 +336,7 @@ func main() {
 		// test mode
 		idParts := strings.Split(cli.Test.Id, ":")
 		if len(idParts) < 2 {
-			log.Error().
+			mainLog.Error().
 				Str("id", cli.Test.Id).
 				Msg("An invalid id was provided")
 			return
@@ -287,7 +352,7 @@ func main() {
 		case "tvdb":
 			testItem.TvdbId = idParts[1]
 		default:
-			log.Error().
+			mainLog.Error().
 				Str("agent", idParts[0]).
 				Str("id", idParts[1]).
 				Msg("Unsupported agent was provided")
@@ -316,9 +381,33 @@ func main() {
 
 	appState := state.Merge(pvrStates...).DependsOn(rssState)
 	if err := appState.Shutdown(appCtx); err != nil {
-		log.Error().
+		mainLog.Error().
 			Err(err).
 			Msg("Failed shutting down gracefully")
 		return
 	}
+
+	if err := metricsShutdown(appCtx); err != nil {
+		mainLog.Error().
+			Err(err).
+			Msg("Failed shutting down metrics server")
+	}
+
+	if err := adminShutdown(appCtx); err != nil {
+		mainLog.Error().
+			Err(err).
+			Msg("Failed shutting down peernet admin server")
+	}
+}
+
+// cliVerbosityLevel maps the -v counter flag to a zerolog level string.
+func cliVerbosityLevel() string {
+	switch {
+	case cli.Verbosity == 1:
+		return "debug"
+	case cli.Verbosity > 1:
+		return "trace"
+	default:
+		return "info"
+	}
 }
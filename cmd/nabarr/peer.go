@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/l3uddz/nabarr/cache"
+	"github.com/l3uddz/nabarr/logger"
+	"github.com/l3uddz/nabarr/peernet"
+)
+
+// runPeerCommand dispatches every "peer ..." subcommand other than "peer keygen", which main
+// handles before a config file (or node) even exists.
+func runPeerCommand(command string, cfg config, c *cache.Cache, root *logger.Logger) error {
+	switch command {
+	case "peer id":
+		return peerId(cfg, c, root)
+	case "peer ping <multiaddr>":
+		return peerPing(cfg, c, root)
+	case "peer peers":
+		return peerPeers(cfg, c, root)
+	default:
+		return fmt.Errorf("unknown peer command %q", command)
+	}
+}
+
+func peerKeygen() error {
+	identityKey, networkKey, err := peernet.GenerateIdentity()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("identity_key:", identityKey)
+	fmt.Println("network_key: ", networkKey)
+	return nil
+}
+
+func peerId(cfg config, c *cache.Cache, root *logger.Logger) error {
+	n, err := peernet.New(cfg.Peernet, c, root.Sub("peernet"))
+	if err != nil {
+		return fmt.Errorf("initialise peernet: %w", err)
+	}
+	defer n.Close()
+
+	fmt.Println("peer id:", n.Identity())
+	for _, addr := range n.Addrs() {
+		fmt.Printf("%s/p2p/%s\n", addr, n.Identity())
+	}
+	return nil
+}
+
+func peerPing(cfg config, c *cache.Cache, root *logger.Logger) error {
+	n, err := peernet.New(cfg.Peernet, c, root.Sub("peernet"))
+	if err != nil {
+		return fmt.Errorf("initialise peernet: %w", err)
+	}
+	defer n.Close()
+
+	addr := cli.Peer.Ping.Multiaddr
+	latency, err := n.Ping(addr)
+	if err != nil {
+		return fmt.Errorf("ping %s: %w", addr, err)
+	}
+
+	fmt.Printf("%s: %s\n", addr, latency)
+	return nil
+}
+
+// adminStatus mirrors the JSON served by peernet.Node.ServeAdmin's /peers route.
+type adminStatus struct {
+	Identity  string              `json:"identity"`
+	Neighbors []string            `json:"neighbors"`
+	Mesh      map[string][]string `json:"mesh"`
+}
+
+// peerPeers reports neighbors and gossipsub mesh membership by querying the running daemon's
+// admin endpoint rather than creating its own (unconnected) peernet node.
+func peerPeers(cfg config, c *cache.Cache, root *logger.Logger) error {
+	if cfg.Peernet.AdminAddr == "" {
+		return fmt.Errorf("peernet.admin_addr is not configured; set it and restart `nabarr run` to enable `peer peers`")
+	}
+
+	resp, err := http.Get("http://" + cfg.Peernet.AdminAddr + "/peers")
+	if err != nil {
+		return fmt.Errorf("query admin endpoint (is `nabarr run` running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("admin endpoint returned %s", resp.Status)
+	}
+
+	status := new(adminStatus)
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return fmt.Errorf("decode admin response: %w", err)
+	}
+
+	fmt.Println("peer id:", status.Identity)
+	fmt.Println("peers:")
+	for _, addr := range status.Neighbors {
+		fmt.Printf("  %s\n", addr)
+	}
+
+	for _, topic := range []string{"sonarr", "radarr"} {
+		fmt.Printf("%s mesh: %s\n", topic, strings.Join(status.Mesh[topic], ", "))
+	}
+	return nil
+}
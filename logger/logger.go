@@ -0,0 +1,96 @@
+// Package logger builds the per-subsystem zerolog sub-loggers used throughout nabarr, threaded
+// explicitly into each subsystem constructor instead of reaching for the global zerolog logger.
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/natefinch/lumberjack"
+	"github.com/rs/zerolog"
+)
+
+// Config controls how every subsystem logger is constructed.
+type Config struct {
+	// Level is the default level (e.g. "debug", "info", "warn") applied unless overridden in Levels.
+	Level string `yaml:"level"`
+	// Format is either "console" (default) or "json".
+	Format string `yaml:"format,omitempty"`
+	// Levels overrides Level per subsystem (e.g. "rss", "pvr:sonarr", "peernet", "cache", "media").
+	Levels map[string]string `yaml:"levels,omitempty"`
+	Syslog SyslogConfig      `yaml:"syslog,omitempty"`
+}
+
+// SyslogConfig adds an optional syslog sink alongside the usual console/file output.
+type SyslogConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Network string `yaml:"network,omitempty"` // "tcp", "udp", or empty for the local syslog socket
+	Addr    string `yaml:"addr,omitempty"`
+	Tag     string `yaml:"tag,omitempty"`
+}
+
+// Logger builds subsystem sub-loggers that all share the same set of sinks.
+type Logger struct {
+	cfg Config
+	out io.Writer
+}
+
+// New constructs the root Logger. logFile is where the rotated file sink writes to; console
+// output always additionally goes to stderr.
+func New(cfg Config, logFile string) (*Logger, error) {
+	fileSink := &lumberjack.Logger{
+		Filename:   logFile,
+		MaxSize:    5,
+		MaxAge:     14,
+		MaxBackups: 5,
+	}
+
+	var writers []io.Writer
+	if cfg.Format == "json" {
+		writers = []io.Writer{os.Stderr, fileSink}
+	} else {
+		writers = []io.Writer{
+			zerolog.ConsoleWriter{
+				TimeFormat: time.Stamp,
+				Out:        os.Stderr,
+			},
+			zerolog.ConsoleWriter{
+				TimeFormat: time.Stamp,
+				Out:        fileSink,
+				NoColor:    true,
+			},
+		}
+	}
+
+	if cfg.Syslog.Enabled {
+		sw, err := newSyslogWriter(cfg.Syslog)
+		if err != nil {
+			return nil, fmt.Errorf("init syslog sink: %w", err)
+		}
+		writers = append(writers, sw)
+	}
+
+	return &Logger{cfg: cfg, out: io.MultiWriter(writers...)}, nil
+}
+
+// Sub returns a zerolog.Logger for the given subsystem, honoring any Config.Levels override.
+func (l *Logger) Sub(subsystem string) zerolog.Logger {
+	levelStr := l.cfg.Level
+	if override, ok := l.cfg.Levels[subsystem]; ok {
+		levelStr = override
+	}
+
+	level, err := zerolog.ParseLevel(levelStr)
+	if err != nil {
+		level = zerolog.InfoLevel
+	}
+
+	return zerolog.New(l.out).
+		With().
+		Timestamp().
+		Str("subsystem", subsystem).
+		Logger().
+		Level(level)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package logger
+
+import (
+	"io"
+	"log/syslog"
+)
+
+func newSyslogWriter(cfg SyslogConfig) (io.Writer, error) {
+	tag := cfg.Tag
+	if tag == "" {
+		tag = "nabarr"
+	}
+
+	w, err := syslog.Dial(cfg.Network, cfg.Addr, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
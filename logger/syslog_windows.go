@@ -0,0 +1,12 @@
+//go:build windows
+
+package logger
+
+import (
+	"fmt"
+	"io"
+)
+
+func newSyslogWriter(SyslogConfig) (io.Writer, error) {
+	return nil, fmt.Errorf("syslog sink is not supported on windows")
+}
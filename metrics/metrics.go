@@ -0,0 +1,82 @@
+// Package metrics exposes Prometheus collectors and the HTTP server that serves them.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// Config controls whether (and where) the metrics endpoint is served.
+type Config struct {
+	ListenAddr string `yaml:"listen_addr,omitempty"`
+}
+
+var (
+	// pvr
+	PvrItemsFiltered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nabarr",
+		Subsystem: "pvr",
+		Name:      "items_filtered_total",
+		Help:      "Number of items filtered out (not queued) per pvr.",
+	}, []string{"pvr"})
+	PvrItemsQueued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nabarr",
+		Subsystem: "pvr",
+		Name:      "items_queued_total",
+		Help:      "Number of items queued per pvr.",
+	}, []string{"pvr"})
+
+	// peernet
+	PeernetMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nabarr",
+		Subsystem: "peernet",
+		Name:      "messages_total",
+		Help:      "Number of peernet messages, labeled by topic and outcome (received/broadcast/rejected).",
+	}, []string{"topic", "outcome"})
+
+	// cache
+	CacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nabarr",
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "Number of cache lookups that found a value, per bucket.",
+	}, []string{"bucket"})
+	CacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nabarr",
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "Number of cache lookups that found nothing, per bucket.",
+	}, []string{"bucket"})
+)
+
+// Serve starts the metrics HTTP server in the background if cfg.ListenAddr is set, and returns a
+// shutdown func (a no-op if the server was never started).
+func Serve(cfg Config) (shutdown func(ctx context.Context) error, err error) {
+	if cfg.ListenAddr == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	srv := &http.Server{
+		Addr:    cfg.ListenAddr,
+		Handler: mux,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().
+				Err(err).
+				Msg("Metrics server failed")
+		}
+	}()
+
+	return srv.Shutdown, nil
+}
@@ -0,0 +1,65 @@
+package peernet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// adminStatus is the payload served at AdminAddr's /peers route.
+type adminStatus struct {
+	Identity  string              `json:"identity"`
+	Neighbors []string            `json:"neighbors"`
+	Mesh      map[string][]string `json:"mesh"`
+}
+
+// ServeAdmin starts a JSON status endpoint on addr in the background if set, and returns a
+// shutdown func (a no-op if addr is empty). `nabarr peer peers` queries this instead of creating
+// its own unconnected node.
+func (n *Node) ServeAdmin(addr string) (shutdown func(ctx context.Context) error, err error) {
+	if addr == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/peers", func(w http.ResponseWriter, r *http.Request) {
+		neighbors, err := n.Neighbors()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		addrs := make([]string, 0, len(neighbors))
+		for _, a := range neighbors {
+			addrs = append(addrs, a.String())
+		}
+
+		mesh := map[string][]string{
+			topicSonarr: n.MeshPeers(topicSonarr),
+			topicRadarr: n.MeshPeers(topicRadarr),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(adminStatus{
+			Identity:  n.Identity(),
+			Neighbors: addrs,
+			Mesh:      mesh,
+		})
+	})
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			n.log.Error().
+				Err(err).
+				Msg("Admin status server failed")
+		}
+	}()
+
+	return srv.Shutdown, nil
+}
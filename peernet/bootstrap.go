@@ -0,0 +1,98 @@
+package peernet
+
+import (
+	"time"
+)
+
+// defaultReconnectInterval is how often unreachable bootstrap nodes are retried in the background.
+const defaultReconnectInterval = 30 * time.Second
+
+// bootstrapAttempts is the number of backed-off attempts made against each node before handing
+// off to the periodic reconnection loop.
+const bootstrapAttempts = 3
+
+// Bootstrap connects to every configured BootstrapNodes entry, tolerating partial failure, then
+// joins the overlay and starts a background retry loop for any peer that couldn't be reached.
+func (n *Node) Bootstrap() error {
+	if len(n.cfg.BootstrapNodes) == 0 {
+		return nil
+	}
+
+	connected := 0
+	for _, addr := range n.cfg.BootstrapNodes {
+		if err := n.connectBootstrapNode(addr); err != nil {
+			n.log.Warn().
+				Err(err).
+				Str("bootstrap_node", addr).
+				Msg("Failed connecting to bootstrap peer, will keep retrying in the background")
+			continue
+		}
+		connected++
+	}
+
+	n.host.JoinOverlay(n.ctx)
+
+	n.log.Info().
+		Int("connected", connected).
+		Int("configured", len(n.cfg.BootstrapNodes)).
+		Msg("Connected to peer network")
+
+	go n.maintainBootstrapNodes()
+	return nil
+}
+
+// connectBootstrapNode retries a single bootstrap node with exponential backoff.
+func (n *Node) connectBootstrapNode(addr string) error {
+	ma, err := parseMultiaddrs([]string{addr})
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Second
+	var lastErr error
+	for attempt := 0; attempt < bootstrapAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-n.ctx.Done():
+				return n.ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		if err := n.host.ConnectWithMultiaddr(n.ctx, ma[0]); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+
+	return lastErr
+}
+
+// maintainBootstrapNodes periodically retries every configured bootstrap node.
+func (n *Node) maintainBootstrapNodes() {
+	interval := n.cfg.ReconnectInterval
+	if interval == 0 {
+		interval = defaultReconnectInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, addr := range n.cfg.BootstrapNodes {
+				if err := n.connectBootstrapNode(addr); err != nil {
+					n.log.Debug().
+						Err(err).
+						Str("bootstrap_node", addr).
+						Msg("Still unable to reach bootstrap peer")
+				}
+			}
+		}
+	}
+}
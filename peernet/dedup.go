@@ -0,0 +1,55 @@
+package peernet
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"time"
+
+	"github.com/l3uddz/nabarr/cache"
+	"github.com/l3uddz/nabarr/metrics"
+)
+
+// contentSeenBucket is the cache bucket used to dedup feed items forwarded across the mesh.
+const contentSeenBucket = "peernet:seen"
+
+func hashKey(parts ...string) string {
+	h := sha1.New()
+	for i, p := range parts {
+		if i > 0 {
+			h.Write([]byte("|"))
+		}
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// seenRecently reports whether bucket/key was already recorded within ttl, marking it seen otherwise.
+func seenRecently(c *cache.Cache, bucket, key string, ttl time.Duration) (bool, error) {
+	if _, ok, err := c.Get(bucket, key); err != nil {
+		return false, err
+	} else if ok {
+		metrics.CacheHits.WithLabelValues(bucket).Inc()
+		return true, nil
+	}
+	metrics.CacheMisses.WithLabelValues(bucket).Inc()
+
+	if err := c.Set(bucket, key, []byte{1}, ttl); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+func (n *Node) seenRecently(bucket, key string, ttl time.Duration) (bool, error) {
+	return seenRecently(n.cache, bucket, key, ttl)
+}
+
+// Seen exposes the peernet dedup cache so callers forwarding feed items on to PVRs, or
+// re-broadcasting them locally, can avoid queuing the same item twice.
+func Seen(c *cache.Cache, key string, ttl time.Duration) (bool, error) {
+	return seenRecently(c, contentSeenBucket, key, ttl)
+}
+
+// HashKey builds the dedup key Seen expects out of a feed item's identifying fields.
+func HashKey(parts ...string) string {
+	return hashKey(parts...)
+}
@@ -0,0 +1,57 @@
+package peernet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Identity returns this node's derived peer ID.
+func (n *Node) Identity() string {
+	return n.host.HostIdentity()
+}
+
+// Addrs returns the multiaddrs this node is listening/advertising on.
+func (n *Node) Addrs() []multiaddr.Multiaddr {
+	return n.host.Addrs()
+}
+
+// Neighbors returns the multiaddrs of peers known via the DHT/overlay.
+func (n *Node) Neighbors() ([]multiaddr.Multiaddr, error) {
+	infos, err := n.host.Neighbors(n.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list neighbors: %w", err)
+	}
+
+	addrs := make([]multiaddr.Multiaddr, 0, len(infos))
+	for _, info := range infos {
+		addrs = append(addrs, info.Addrs...)
+	}
+	return addrs, nil
+}
+
+// MeshPeers returns the peer IDs currently in the gossipsub mesh for topic.
+func (n *Node) MeshPeers(topic string) []string {
+	peers := n.host.ListPeers(topic)
+	ids := make([]string, 0, len(peers))
+	for _, p := range peers {
+		ids = append(ids, p.String())
+	}
+	return ids
+}
+
+// Ping connects to addr and reports how long the connection took to establish, as a coarse
+// reachability/latency check for network diagnostics.
+func (n *Node) Ping(addr string) (time.Duration, error) {
+	ma, err := multiaddr.NewMultiaddr(addr)
+	if err != nil {
+		return 0, fmt.Errorf("parse multiaddr: %w", err)
+	}
+
+	start := time.Now()
+	if err := n.host.ConnectWithMultiaddr(n.ctx, ma); err != nil {
+		return 0, fmt.Errorf("connect: %w", err)
+	}
+	return time.Since(start), nil
+}
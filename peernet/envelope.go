@@ -0,0 +1,104 @@
+package peernet
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// envelopeBucket is the cache bucket used to dedup signed envelopes (contentSeenBucket dedups on
+// feed item identity instead).
+const envelopeBucket = "peernet:envelope"
+
+const (
+	defaultMaxClockSkew = 30 * time.Second
+	defaultEnvelopeTTL  = 10 * time.Minute
+)
+
+// envelope wraps every payload broadcast over peernet with a publisher signature and timestamp.
+type envelope struct {
+	Payload []byte `json:"payload"`
+	PubKey  string `json:"pub_key"`
+	Sig     []byte `json:"sig"`
+	Ts      int64  `json:"ts"`
+}
+
+func (n *Node) identityKey() (ed25519.PrivateKey, error) {
+	seed, err := hex.DecodeString(n.cfg.IdentityKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode identity_key: %w", err)
+	}
+
+	switch len(seed) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(seed), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(seed), nil
+	default:
+		return nil, fmt.Errorf("identity_key must be a %d or %d byte hex-encoded ed25519 key", ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+}
+
+func signedBytes(payload []byte, ts int64) []byte {
+	return append([]byte(fmt.Sprintf("%d:", ts)), payload...)
+}
+
+// sealEnvelope signs data with the node's identity key and returns the wire-ready envelope JSON.
+func (n *Node) sealEnvelope(data []byte) ([]byte, error) {
+	priv, err := n.identityKey()
+	if err != nil {
+		return nil, err
+	}
+
+	env := envelope{
+		Payload: data,
+		PubKey:  hex.EncodeToString(priv.Public().(ed25519.PublicKey)),
+		Ts:      time.Now().Unix(),
+	}
+	env.Sig = ed25519.Sign(priv, signedBytes(env.Payload, env.Ts))
+
+	return json.Marshal(env)
+}
+
+// verifyEnvelope checks the publisher is trusted, the signature is valid, and the timestamp is
+// within the configured clock skew. It does not touch the dedup cache.
+func (n *Node) verifyEnvelope(raw []byte) (*envelope, error) {
+	env := new(envelope)
+	if err := json.Unmarshal(raw, env); err != nil {
+		return nil, fmt.Errorf("decode envelope: %w", err)
+	}
+
+	pub, err := hex.DecodeString(env.PubKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid envelope pub_key")
+	}
+
+	if len(n.cfg.TrustedPeers) > 0 && !n.isTrustedPeer(env.PubKey) {
+		return nil, fmt.Errorf("untrusted publisher %s", env.PubKey)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(pub), signedBytes(env.Payload, env.Ts), env.Sig) {
+		return nil, fmt.Errorf("invalid envelope signature")
+	}
+
+	skew := n.cfg.MaxClockSkew
+	if skew == 0 {
+		skew = defaultMaxClockSkew
+	}
+	if delta := time.Since(time.Unix(env.Ts, 0)); delta > skew || delta < -skew {
+		return nil, fmt.Errorf("stale envelope timestamp")
+	}
+
+	return env, nil
+}
+
+func (n *Node) isTrustedPeer(pubKeyHex string) bool {
+	for _, p := range n.cfg.TrustedPeers {
+		if p == pubKeyHex {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package peernet
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// GenerateIdentity creates a fresh Ed25519 identity key and a random pre-shared network key, both
+// hex-encoded ready to paste into config.yml's peernet.identity_key / peernet.network_key.
+func GenerateIdentity() (identityKey, networkKey string, err error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("generate identity key: %w", err)
+	}
+
+	psk := make([]byte, 32)
+	if _, err := rand.Read(psk); err != nil {
+		return "", "", fmt.Errorf("generate network key: %w", err)
+	}
+
+	return hex.EncodeToString(priv.Seed()), hex.EncodeToString(psk), nil
+}
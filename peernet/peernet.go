@@ -2,34 +2,87 @@ package peernet
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
+
 	"github.com/iotexproject/go-p2p"
-	"github.com/l3uddz/nabarr/logger"
+	"github.com/l3uddz/nabarr/cache"
+	"github.com/l3uddz/nabarr/media"
+	"github.com/l3uddz/nabarr/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/rs/zerolog"
 )
 
-type Config struct {
-	ExternalHost  string `yaml:"external_host"`
-	ExternalPort  int    `yaml:"external_port"`
-	IdentityKey   string `yaml:"identity_key"`
-	NetworkKey    string `yaml:"network_key"`
-	BootstrapNode string `yaml:"bootstrap_node"`
+const (
+	topicSonarr = "sonarr"
+	topicRadarr = "radarr"
+)
+
+const (
+	defaultRateLimit         = 60
+	defaultInvalidMsgWeight  = -10
+	defaultGreylistThreshold = -50
+	defaultDedupTTL          = 24 * time.Hour
+)
 
-	Verbosity string `yaml:"verbosity,omitempty"`
+// ScoringConfig controls the gossipsub peer scoring applied to the peernet topics, so a single
+// misbehaving peer in the mesh can be throttled (and eventually pruned) rather than being able to
+// flood every subscriber's PVR queue.
+type ScoringConfig struct {
+	InvalidMessageWeight float64 `yaml:"invalid_message_weight"`
+	RateLimit            int     `yaml:"rate_limit"`
+	GreylistThreshold    float64 `yaml:"greylist_threshold"`
+}
+
+type Config struct {
+	// ListenAddrs are multiaddr strings (e.g. "/ip4/0.0.0.0/tcp/9157", "/ip6/::/tcp/9157",
+	// "/ip4/0.0.0.0/udp/9157/quic-v1", "/dns4/node.example.com/tcp/9157") the host listens and
+	// advertises on. At least one must be provided.
+	ListenAddrs []string `yaml:"listen_addrs"`
+	IdentityKey string   `yaml:"identity_key"`
+	NetworkKey  string   `yaml:"network_key"`
+	// BootstrapNodes are multiaddrs of rendezvous peers to connect to on startup. Connection
+	// failures are logged and don't prevent the node from starting; see Bootstrap.
+	BootstrapNodes []string `yaml:"bootstrap_nodes"`
+	// ReconnectInterval controls how often unreachable bootstrap nodes are retried in the
+	// background. Defaults to defaultReconnectInterval.
+	ReconnectInterval time.Duration `yaml:"reconnect_interval,omitempty"`
+
+	// TrustedPeers, when non-empty, restricts which publishers (identified by their hex-encoded
+	// envelope pub_key) are honored, even though every peer on the network shares the same PSK.
+	TrustedPeers []string `yaml:"trusted_peers,omitempty"`
+	// MaxClockSkew bounds how far a broadcast envelope's timestamp may drift from this node's
+	// clock before it is rejected as stale.
+	MaxClockSkew time.Duration `yaml:"max_clock_skew,omitempty"`
+	// DedupTTL is how long a feed item's identity is remembered (via Seen) to stop gossipsub's
+	// natural re-delivery, or another peer re-broadcasting the same item, from queuing it twice.
+	// Defaults to defaultDedupTTL.
+	DedupTTL time.Duration `yaml:"dedup_ttl,omitempty"`
+
+	// AdminAddr, if set, serves the status endpoint ServeAdmin uses for `nabarr peer peers`.
+	AdminAddr string `yaml:"admin_addr,omitempty"`
+
+	Scoring ScoringConfig `yaml:"scoring"`
 }
 
 type Node struct {
-	cfg  Config
-	ctx  context.Context
-	log  zerolog.Logger
-	host *p2p.Host
+	cfg   Config
+	ctx   context.Context
+	log   zerolog.Logger
+	host  *p2p.Host
+	cache *cache.Cache
+
+	rateMu      sync.Mutex
+	rateWindows map[peer.ID]*peerWindow
 }
 
-func New(c Config) (*Node, error) {
+func New(c Config, ca *cache.Cache, log zerolog.Logger) (*Node, error) {
 	// validate config
-	if c.ExternalHost == "" {
-		return nil, fmt.Errorf("external_host must be provided")
+	if len(c.ListenAddrs) == 0 {
+		return nil, fmt.Errorf("listen_addrs must contain at least one multiaddr")
 	}
 
 	if c.NetworkKey == "" {
@@ -37,16 +90,34 @@ func New(c Config) (*Node, error) {
 	}
 
 	// set config defaults
-	if c.ExternalPort == 0 {
-		c.ExternalPort = 9157
+	if c.Scoring.RateLimit == 0 {
+		c.Scoring.RateLimit = defaultRateLimit
+	}
+	if c.Scoring.InvalidMessageWeight == 0 {
+		c.Scoring.InvalidMessageWeight = defaultInvalidMsgWeight
+	}
+	if c.Scoring.GreylistThreshold == 0 {
+		c.Scoring.GreylistThreshold = defaultGreylistThreshold
+	}
+	if c.DedupTTL == 0 {
+		c.DedupTTL = defaultDedupTTL
+	}
+
+	listenAddrs, err := parseMultiaddrs(c.ListenAddrs)
+	if err != nil {
+		return nil, fmt.Errorf("listen_addrs: %w", err)
+	}
+
+	n := &Node{
+		cfg:         c,
+		ctx:         context.Background(),
+		cache:       ca,
+		rateWindows: make(map[peer.ID]*peerWindow),
 	}
 
 	// init node
 	opts := []p2p.Option{
-		p2p.HostName("0.0.0.0"),
-		p2p.Port(c.ExternalPort),
-		p2p.ExternalHostName(c.ExternalHost),
-		p2p.ExternalPort(c.ExternalPort),
+		p2p.MultiAddrs(listenAddrs...),
 		p2p.DHTProtocolID(1337),
 		p2p.MasterKey(c.IdentityKey),
 		p2p.PrivateNetworkPSK(c.NetworkKey),
@@ -54,61 +125,90 @@ func New(c Config) (*Node, error) {
 		// feats
 		p2p.Gossip(),
 		p2p.SecureIO(),
+		p2p.WithPeerScoreParams(n.peerScoreParams()),
+		p2p.WithPeerScoreThresholds(n.peerScoreThresholds()),
 	}
 
-	ctx := context.Background()
-	host, err := p2p.NewHost(ctx, opts...)
+	host, err := p2p.NewHost(n.ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("new host: %w", err)
 	}
+	n.host = host
 
-	l := logger.New(c.Verbosity).With().
+	n.log = log.With().
 		Str("identity", host.HostIdentity()).
 		Logger()
 
-	n := &Node{
-		cfg:  c,
-		ctx:  ctx,
-		host: host,
-
-		log: l,
-	}
-
 	return n, nil
 }
 
+// Subscribe joins the given gossipsub topic, installing a validator (see validateMessage) ahead
+// of the broadcast callback so malformed, untrusted, or rate-limit-busting messages never reach
+// it. By the time callback runs, the envelope has already been verified, so it only ever sees the
+// original payload passed to Broadcast.
 func (n *Node) Subscribe(topic string, callback p2p.HandleBroadcast) error {
-	return n.host.AddBroadcastPubSub(n.ctx, topic, callback)
-}
-
-func (n *Node) Broadcast(topic string, data []byte) error {
-	return n.host.Broadcast(n.ctx, topic, data)
-}
+	if err := n.host.RegisterTopicValidator(topic, n.validateMessage); err != nil {
+		return fmt.Errorf("register topic validator: %w", err)
+	}
 
-func (n *Node) Bootstrap() error {
-	if n.cfg.BootstrapNode == "" {
-		return nil
+	unwrap := func(ctx context.Context, data []byte) error {
+		env, err := n.verifyEnvelope(data)
+		if err != nil {
+			// the validator already rejected anything that would fail here; this is belt-and-braces.
+			return fmt.Errorf("unwrap envelope: %w", err)
+		}
+		metrics.PeernetMessages.WithLabelValues(topic, "received").Inc()
+		return callback(ctx, env.Payload)
 	}
 
-	n.log.Debug().
-		Str("bootstrap_node", n.cfg.BootstrapNode).
-		Msg("Connecting to peer network")
+	return n.host.AddBroadcastPubSub(n.ctx, topic, unwrap)
+}
 
-	ma, err := multiaddr.NewMultiaddr(n.cfg.BootstrapNode)
+// Broadcast signs data with the node's identity key and publishes the resulting envelope. If data
+// decodes as a media.FeedItem, it is also marked as seen in the Seen dedup cache, so the mesh
+// relaying it back to us doesn't queue it twice.
+func (n *Node) Broadcast(topic string, data []byte) error {
+	env, err := n.sealEnvelope(data)
 	if err != nil {
-		return fmt.Errorf("parse bootstrap_node: %w", err)
+		return fmt.Errorf("seal envelope: %w", err)
 	}
 
-	if err := n.host.ConnectWithMultiaddr(n.ctx, ma); err != nil {
-		return fmt.Errorf("connect bootstrap_node: %w", err)
+	var fi media.FeedItem
+	if err := json.Unmarshal(data, &fi); err == nil {
+		key := hashKey(fi.Guid, fi.ImdbId, fi.TvdbId, fi.TmdbId, fi.Title)
+		if _, err := n.seenRecently(contentSeenBucket, key, n.cfg.DedupTTL); err != nil {
+			n.log.Error().
+				Err(err).
+				Msg("Failed marking locally-broadcast feed item as seen")
+		}
 	}
 
-	n.host.JoinOverlay(n.ctx)
+	if err := n.host.Broadcast(n.ctx, topic, env); err != nil {
+		return err
+	}
 
-	n.log.Info().Msg("Connected to peer network")
+	metrics.PeernetMessages.WithLabelValues(topic, "broadcast").Inc()
 	return nil
 }
 
+// DedupTTL returns the resolved (post-default) dedup TTL, for callers checking Seen against the
+// same cache bucket/TTL this node uses internally.
+func (n *Node) DedupTTL() time.Duration {
+	return n.cfg.DedupTTL
+}
+
 func (n *Node) Close() error {
 	return n.host.Close()
 }
+
+func parseMultiaddrs(addrs []string) ([]multiaddr.Multiaddr, error) {
+	mas := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		ma, err := multiaddr.NewMultiaddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("parse multiaddr %q: %w", a, err)
+		}
+		mas = append(mas, ma)
+	}
+	return mas, nil
+}
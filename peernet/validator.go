@@ -0,0 +1,154 @@
+package peernet
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/l3uddz/nabarr/media"
+	"github.com/l3uddz/nabarr/metrics"
+)
+
+// maxMessageSize is the largest payload accepted for a peernet feed item.
+const maxMessageSize = 1 << 16 // 64KiB
+
+// rateLimitScorePenalty is the appSpecificScore penalty per message over Config.Scoring.RateLimit.
+const rateLimitScorePenalty = -1
+
+// peerWindow tracks message counts per peer within the current rate limit window.
+type peerWindow struct {
+	windowStart time.Time
+	count       int
+}
+
+// validateMessage is the gossipsub topic validator: envelope, message shape, rate limit, dedup.
+func (n *Node) validateMessage(_ context.Context, pid peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	topic := msg.GetTopic()
+
+	if len(msg.Data) > maxMessageSize {
+		n.log.Debug().
+			Str("peer", pid.String()).
+			Int("size", len(msg.Data)).
+			Msg("Rejecting oversized peernet message")
+		metrics.PeernetMessages.WithLabelValues(topic, "rejected").Inc()
+		return pubsub.ValidationReject
+	}
+
+	env, err := n.verifyEnvelope(msg.Data)
+	if err != nil {
+		n.log.Debug().
+			Err(err).
+			Str("peer", pid.String()).
+			Msg("Rejecting peernet envelope")
+		metrics.PeernetMessages.WithLabelValues(topic, "rejected").Inc()
+		return pubsub.ValidationReject
+	}
+
+	fi := new(media.FeedItem)
+	if err := json.Unmarshal(env.Payload, fi); err != nil {
+		n.log.Debug().
+			Err(err).
+			Str("peer", pid.String()).
+			Msg("Rejecting malformed peernet message")
+		metrics.PeernetMessages.WithLabelValues(topic, "rejected").Inc()
+		return pubsub.ValidationReject
+	}
+
+	if !n.allowPeerMessage(pid) {
+		n.log.Debug().
+			Str("peer", pid.String()).
+			Msg("Ignoring peernet message, peer exceeded rate limit")
+		metrics.PeernetMessages.WithLabelValues(topic, "rejected").Inc()
+		return pubsub.ValidationIgnore
+	}
+
+	ttl := defaultEnvelopeTTL
+	seen, err := n.seenRecently(envelopeBucket, env.PubKey+":"+hashKey(string(env.Payload)), ttl)
+	if err != nil {
+		n.log.Error().
+			Err(err).
+			Msg("Failed checking peernet envelope dedup cache")
+		return pubsub.ValidationIgnore
+	}
+	if seen {
+		n.log.Debug().
+			Str("peer", pid.String()).
+			Msg("Ignoring duplicate/replayed peernet envelope")
+		metrics.PeernetMessages.WithLabelValues(topic, "rejected").Inc()
+		return pubsub.ValidationIgnore
+	}
+
+	return pubsub.ValidationAccept
+}
+
+// allowPeerMessage reports whether pid is still within Config.Scoring.RateLimit this window.
+func (n *Node) allowPeerMessage(pid peer.ID) bool {
+	n.rateMu.Lock()
+	defer n.rateMu.Unlock()
+
+	w, ok := n.rateWindows[pid]
+	now := time.Now()
+	if !ok || now.Sub(w.windowStart) >= time.Minute {
+		w = &peerWindow{windowStart: now}
+		n.rateWindows[pid] = w
+	}
+
+	w.count++
+	return w.count <= n.cfg.Scoring.RateLimit
+}
+
+// appSpecificScore is the gossipsub ApplicationSpecificScore hook; penalises rate-limited peers.
+func (n *Node) appSpecificScore(pid peer.ID) float64 {
+	n.rateMu.Lock()
+	defer n.rateMu.Unlock()
+
+	w, ok := n.rateWindows[pid]
+	if !ok || w.count <= n.cfg.Scoring.RateLimit {
+		return 0
+	}
+	return float64(w.count-n.cfg.Scoring.RateLimit) * rateLimitScorePenalty
+}
+
+// peerScoreParams builds the gossipsub scoring config from Config.Scoring.
+func (n *Node) peerScoreParams() *pubsub.PeerScoreParams {
+	topicParams := &pubsub.TopicScoreParams{
+		TopicWeight:                    1,
+		InvalidMessageDeliveriesWeight: n.cfg.Scoring.InvalidMessageWeight,
+		InvalidMessageDeliveriesDecay:  0.5,
+		TimeInMeshWeight:               0.01,
+		TimeInMeshQuantum:              time.Minute,
+		TimeInMeshCap:                  10,
+		FirstMessageDeliveriesWeight:   1,
+		FirstMessageDeliveriesDecay:    0.5,
+		FirstMessageDeliveriesCap:      50,
+		MeshMessageDeliveriesWeight:    0,
+		MeshMessageDeliveriesDecay:     0.5,
+		MeshFailurePenaltyWeight:       0,
+		MeshFailurePenaltyDecay:        0.5,
+	}
+
+	return &pubsub.PeerScoreParams{
+		AppSpecificScore:  n.appSpecificScore,
+		AppSpecificWeight: 1,
+		DecayInterval:     time.Minute,
+		DecayToZero:       0.01,
+		Topics: map[string]*pubsub.TopicScoreParams{
+			topicSonarr: topicParams,
+			topicRadarr: topicParams,
+		},
+	}
+}
+
+// peerScoreThresholds derives the gossipsub thresholds from Config.Scoring.GreylistThreshold.
+func (n *Node) peerScoreThresholds() *pubsub.PeerScoreThresholds {
+	return &pubsub.PeerScoreThresholds{
+		GossipThreshold:             n.cfg.Scoring.GreylistThreshold / 4,
+		PublishThreshold:            n.cfg.Scoring.GreylistThreshold / 2,
+		GraylistThreshold:           n.cfg.Scoring.GreylistThreshold,
+		AcceptPXThreshold:           0,
+		OpportunisticGraftThreshold: 0,
+	}
+}